@@ -1,142 +1,122 @@
 package main
 
 import (
-	"fmt"
 	"log"
 	"net/http"
-	"strings"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/grpc-ecosystem/grpc-gateway/runtime"
 	_ "github.com/joho/godotenv/autoload"
 	context "golang.org/x/net/context"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/protobuf/encoding/protojson"
 
+	"github.com/aodin/grpc/gateway/swaggerui"
 	things "github.com/aodin/grpc/go"
 	"github.com/aodin/grpc/server"
+	"github.com/aodin/grpc/server/cors"
+	gw "github.com/aodin/grpc/server/gateway"
+	"github.com/aodin/grpc/server/httplog"
 )
 
 var (
 	Addr = "localhost:8080"
-)
-
-// newGateway returns a new gateway server which translates HTTP into gRPC.
-func newGateway(ctx context.Context, opts ...runtime.ServeMuxOption) (http.Handler, error) {
-	mux := runtime.NewServeMux(opts...)
 
-	creds, err := credentials.NewClientTLSFromFile(server.GetCertFile(), "")
-	if err != nil {
-		return mux, fmt.Errorf("TLS creation failed: %v", err)
-	}
-
-	// If using TLS
-	dialOpts := []grpc.DialOption{grpc.WithTransportCredentials(creds)}
-
-	// If not using TLS
-	// dialOpts := []grpc.DialOption{grpc.WithInsecure()}
+	// ShutdownTimeout bounds how long New waits for in-flight requests
+	// to drain after receiving SIGINT/SIGTERM before forcing a close.
+	ShutdownTimeout = 15 * time.Second
+)
 
-	if err := things.RegisterThingsHandlerFromEndpoint(ctx, mux, server.Addr, dialOpts); err != nil {
-		return nil, err
-	}
-	return mux, nil
+// jsonMarshaler matches the field naming and presence behavior of the
+// upstream proto definitions: proto field names rather than lowerCamel,
+// and zero-valued fields still present in the response.
+var jsonMarshaler = &runtime.JSONPb{
+	MarshalOptions: protojson.MarshalOptions{
+		EmitUnpopulated: true,
+		UseProtoNames:   true,
+	},
 }
 
-// handleCORS allows Cross Origin Resoruce Sharing from any origin.
-// Don't do this without consideration in production systems.
-func handleCORS(h http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if origin := r.Header.Get("Origin"); origin != "" {
-			w.Header().Set("Access-Control-Allow-Origin", origin)
-			if r.Method == "OPTIONS" && r.Header.Get("Access-Control-Request-Method") != "" {
-				preflightHandler(w, r)
-				return
-			}
-		}
-		h.ServeHTTP(w, r)
-	})
+// newGateway builds the Gateway that fronts the things service.
+func newGateway() *gw.Gateway {
+	corsPolicy := cors.New(cors.ConfigFromEnv())
+	accessLog := httplog.New(httplog.WithFormat(httplog.FormatFromEnv()))
+
+	return gw.New(
+		gw.WithEndpoint(server.Addr),
+		gw.WithTLS(server.GetCertFile(), ""),
+		gw.WithMarshaler(jsonMarshaler),
+		gw.WithServiceHandler(things.RegisterThingsHandler),
+		gw.WithSwagger(swaggerui.FS, "things.swagger.json"),
+		gw.WithMiddleware(accessLog.Wrap),
+		gw.WithMiddleware(corsPolicy.Handler),
+	)
 }
 
-// loggingResponseWriter records the status code of the response
-type loggingResponseWriter struct {
-	http.ResponseWriter
-	statusCode int
+// healthzHandler reports that the process is alive.
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok"))
 }
 
-func NewLoggingResponseWriter(w http.ResponseWriter) *loggingResponseWriter {
-	return &loggingResponseWriter{w, http.StatusOK}
+// readyzHandler reports whether the upstream gRPC connection is ready to
+// serve traffic.
+func readyzHandler(g *gw.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if g.ClientConn().GetState() != connectivity.Ready {
+			http.Error(w, "upstream not ready", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+	}
 }
 
-func (lrw *loggingResponseWriter) WriteHeader(code int) {
-	lrw.statusCode = code
-	lrw.ResponseWriter.WriteHeader(code)
-}
+// New starts a HTTP server and blocks until it receives SIGINT/SIGTERM,
+// at which point it drains in-flight requests and closes the upstream
+// gRPC connection before returning.
+func New(address string) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-func logRequestWrapper(h http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Wrap the response writer in order to save status code
-		writer := NewLoggingResponseWriter(w)
-		start := time.Now()
-		h.ServeHTTP(writer, r) // The original request is evaluated here
-		elapsed := time.Now().Sub(start).Seconds()
-		log.Printf(
-			"%d %s %s %.6f",
-			writer.statusCode, r.Method, r.URL, elapsed,
-		)
-	})
-}
+	gateway := newGateway()
+	handler, err := gateway.Build(ctx)
+	if err != nil {
+		return err
+	}
+	defer gateway.Close()
 
-// headers is allowed CORS headers
-var headers = []string{
-	"Accept",
-	"Accept-Encoding",
-	"Authorization",
-	"Content-Type",
-	"Origin",
-	"User-Agent",
-	"X-CSRF-Token",
-	"X-CSRFToken",
-	"X-Request-ID",
-	"X-Requested-With",
-}
+	mux := http.NewServeMux()
+	mux.Handle("/", handler)
+	mux.HandleFunc("/healthz", healthzHandler)
+	mux.HandleFunc("/readyz", readyzHandler(gateway))
 
-// methods are the HTTP methods allowed by CORS
-var methods = []string{
-	http.MethodGet,
-	http.MethodHead,
-	http.MethodPost,
-	http.MethodPut,
-	http.MethodPatch,
-	http.MethodDelete,
-}
+	srv := &http.Server{Addr: address, Handler: mux}
 
-// preflightHandler sets headers values for CORS and then short-circuits
-// the request
-func preflightHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Access-Control-Allow-Headers", strings.Join(headers, ","))
-	w.Header().Set("Access-Control-Allow-Methods", strings.Join(methods, ","))
-	w.Header().Set("Access-Control-Max-Age", "3600") // One hour
-	return
-}
+	serveErrs := make(chan error, 1)
+	go func() {
+		log.Printf("starting gateway server on %s\n", address)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			serveErrs <- err
+		}
+		close(serveErrs)
+	}()
 
-// Run starts a HTTP server and blocks forever if successful.
-func New(address string, opts ...runtime.ServeMuxOption) error {
-	ctx := context.Background()
-	ctx, cancel := context.WithCancel(ctx)
-	defer cancel()
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
 
-	mux := http.NewServeMux()
-	gw, err := newGateway(ctx, opts...)
-	if err != nil {
+	select {
+	case err := <-serveErrs:
 		return err
+	case <-sig:
+		log.Printf("shutting down gateway server\n")
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+		defer shutdownCancel()
+		return srv.Shutdown(shutdownCtx)
 	}
-	mux.Handle("/", gw)
-	log.Printf("starting gateway server on %s\n", Addr)
-
-	return http.ListenAndServe(
-		address,
-		logRequestWrapper(handleCORS(mux)),
-	)
 }
 
 func main() {
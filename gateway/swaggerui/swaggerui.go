@@ -0,0 +1,17 @@
+// Package swaggerui embeds a self-contained API doc viewer and the
+// things service's OpenAPI document, for use with gateway.WithSwagger.
+//
+// things.swagger.json is a placeholder checked in until the things.proto
+// build (in the separate github.com/aodin/grpc/go module) produces the
+// real protoc-gen-swagger output; replace it wholesale with that
+// generated file rather than editing it by hand.
+//
+// index.html has no external script/stylesheet dependencies (no CDN
+// fetches at request time) so /docs/ works in offline and
+// restricted-egress deployments.
+package swaggerui
+
+import "embed"
+
+//go:embed things.swagger.json index.html
+var FS embed.FS
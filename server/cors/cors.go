@@ -0,0 +1,345 @@
+// Package cors implements Cross-Origin Resource Sharing (CORS) as a
+// configurable HTTP middleware, modeled after github.com/rs/cors. It
+// replaces the previous hardcoded origin-reflection behavior in the
+// gateway with an explicit, auditable policy.
+package cors
+
+import (
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Config describes the CORS policy to enforce.
+type Config struct {
+	// AllowedOrigins is a list of origins a cross-domain request can be
+	// executed from. Entries may be "*" (match any origin) or contain a
+	// single "*" wildcard, e.g. "https://*.example.com". Defaults to
+	// allowing no origins if left empty and AllowedOriginFunc is nil.
+	AllowedOrigins []string
+
+	// AllowedOriginFunc is a custom function to validate the origin. It
+	// takes the origin as an argument and returns true if allowed. It is
+	// used in preference to AllowedOrigins if set.
+	AllowedOriginFunc func(origin string) bool
+
+	// AllowedMethods is the list of methods the client is allowed to use
+	// with cross-domain requests. Defaults to the methods supported by
+	// the things API.
+	AllowedMethods []string
+
+	// AllowedHeaders is the list of non simple headers the client is
+	// allowed to use with cross-domain requests.
+	AllowedHeaders []string
+
+	// ExposedHeaders indicates which headers are safe to expose to the
+	// API of a CORS API specification.
+	ExposedHeaders []string
+
+	// AllowCredentials indicates whether the request can include user
+	// credentials like cookies, HTTP authentication or client side SSL
+	// certificates. It is an error to combine this with AllowedOrigins
+	// containing "*".
+	AllowCredentials bool
+
+	// MaxAge indicates how long (in seconds) the results of a preflight
+	// request can be cached. Defaults to 3600 (one hour) if zero.
+	MaxAge int
+
+	// OptionsPassthrough instructs the preflight handler to set the CORS
+	// response headers and then pass the OPTIONS request through to the
+	// next handler, instead of short-circuiting it with a 204. Useful
+	// when another handler (e.g. a router) needs to see OPTIONS requests.
+	OptionsPassthrough bool
+}
+
+// ConfigFromEnv builds a Config from environment variables, suitable for
+// use with github.com/joho/godotenv. Recognized variables:
+//
+//	CORS_ALLOWED_ORIGINS      comma-separated list, e.g. "https://*.example.com,https://example.com"
+//	CORS_ALLOWED_METHODS      comma-separated list
+//	CORS_ALLOWED_HEADERS      comma-separated list
+//	CORS_EXPOSED_HEADERS      comma-separated list
+//	CORS_ALLOW_CREDENTIALS    "true" or "false"
+//	CORS_MAX_AGE              seconds, e.g. "3600"
+//	CORS_OPTIONS_PASSTHROUGH  "true" or "false"
+func ConfigFromEnv() Config {
+	cfg := Config{
+		AllowedOrigins:     splitEnvList("CORS_ALLOWED_ORIGINS"),
+		AllowedMethods:     splitEnvList("CORS_ALLOWED_METHODS"),
+		AllowedHeaders:     splitEnvList("CORS_ALLOWED_HEADERS"),
+		ExposedHeaders:     splitEnvList("CORS_EXPOSED_HEADERS"),
+		AllowCredentials:   os.Getenv("CORS_ALLOW_CREDENTIALS") == "true",
+		OptionsPassthrough: os.Getenv("CORS_OPTIONS_PASSTHROUGH") == "true",
+	}
+	if v := os.Getenv("CORS_MAX_AGE"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			cfg.MaxAge = n
+		}
+	}
+	if len(cfg.AllowedMethods) == 0 {
+		cfg.AllowedMethods = defaultMethods
+	}
+	if len(cfg.AllowedHeaders) == 0 {
+		cfg.AllowedHeaders = defaultHeaders
+	}
+	return cfg
+}
+
+func splitEnvList(key string) []string {
+	v := os.Getenv(key)
+	if v == "" {
+		return nil
+	}
+	parts := strings.Split(v, ",")
+	out := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// defaultMethods are the HTTP methods allowed by CORS when none are
+// configured.
+var defaultMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+}
+
+// defaultHeaders are the allowed CORS headers when none are configured.
+var defaultHeaders = []string{
+	"Accept",
+	"Accept-Encoding",
+	"Authorization",
+	"Content-Type",
+	"Origin",
+	"User-Agent",
+	"X-CSRF-Token",
+	"X-CSRFToken",
+	"X-Request-ID",
+	"X-Requested-With",
+}
+
+// Cors enforces a Config's CORS policy.
+type Cors struct {
+	allowedOrigins    []string
+	allowedWildcards  []wildcard
+	allowedOriginFunc func(origin string) bool
+	allowAllOrigins   bool
+
+	allowedMethods map[string]bool
+	allowedHeaders map[string]bool
+	exposedHeaders string
+
+	allowCredentials   bool
+	maxAge             string
+	optionsPassthrough bool
+}
+
+// New builds a Cors from cfg. It panics if AllowCredentials is combined
+// with a wildcard "*" origin, which browsers forbid and which would
+// otherwise silently fail at request time.
+func New(cfg Config) *Cors {
+	c := &Cors{
+		allowedOriginFunc:  cfg.AllowedOriginFunc,
+		allowCredentials:   cfg.AllowCredentials,
+		optionsPassthrough: cfg.OptionsPassthrough,
+	}
+
+	for _, o := range cfg.AllowedOrigins {
+		o = strings.ToLower(o)
+		if o == "*" {
+			c.allowAllOrigins = true
+			continue
+		}
+		if strings.Contains(o, "*") {
+			c.allowedWildcards = append(c.allowedWildcards, newWildcard(o))
+			continue
+		}
+		c.allowedOrigins = append(c.allowedOrigins, o)
+	}
+
+	if c.allowAllOrigins && c.allowCredentials {
+		panic("cors: AllowCredentials cannot be used with a wildcard AllowedOrigins entry")
+	}
+
+	methods := cfg.AllowedMethods
+	if len(methods) == 0 {
+		methods = defaultMethods
+	}
+	c.allowedMethods = make(map[string]bool, len(methods))
+	for _, m := range methods {
+		c.allowedMethods[strings.ToUpper(m)] = true
+	}
+
+	headers := cfg.AllowedHeaders
+	if len(headers) == 0 {
+		headers = defaultHeaders
+	}
+	c.allowedHeaders = make(map[string]bool, len(headers))
+	for _, h := range headers {
+		c.allowedHeaders[http.CanonicalHeaderKey(h)] = true
+	}
+
+	if len(cfg.ExposedHeaders) > 0 {
+		c.exposedHeaders = strings.Join(cfg.ExposedHeaders, ", ")
+	}
+
+	maxAge := cfg.MaxAge
+	if maxAge == 0 {
+		maxAge = 3600
+	}
+	c.maxAge = strconv.Itoa(maxAge)
+
+	return c
+}
+
+// Handler wraps h, enforcing the CORS policy on every request and
+// answering preflight OPTIONS requests directly.
+func (c *Cors) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			c.handlePreflight(w, r)
+			if c.optionsPassthrough {
+				h.ServeHTTP(w, r)
+			}
+			return
+		}
+
+		c.handleActual(w, r)
+		h.ServeHTTP(w, r)
+	})
+}
+
+func (c *Cors) handlePreflight(w http.ResponseWriter, r *http.Request) {
+	headers := w.Header()
+	origin := r.Header.Get("Origin")
+
+	headers.Add("Vary", "Origin")
+	headers.Add("Vary", "Access-Control-Request-Method")
+	headers.Add("Vary", "Access-Control-Request-Headers")
+
+	if !c.isOriginAllowed(origin) {
+		return
+	}
+
+	method := r.Header.Get("Access-Control-Request-Method")
+	if !c.allowedMethods[strings.ToUpper(method)] {
+		return
+	}
+
+	reqHeaders := parseHeaderList(r.Header.Get("Access-Control-Request-Headers"))
+	for _, rh := range reqHeaders {
+		if !c.allowedHeaders[http.CanonicalHeaderKey(rh)] {
+			return
+		}
+	}
+
+	c.setAllowOrigin(headers, origin)
+	headers.Set("Access-Control-Allow-Methods", strings.ToUpper(method))
+	if len(reqHeaders) > 0 {
+		headers.Set("Access-Control-Allow-Headers", r.Header.Get("Access-Control-Request-Headers"))
+	}
+	if c.allowCredentials {
+		headers.Set("Access-Control-Allow-Credentials", "true")
+	}
+	headers.Set("Access-Control-Max-Age", c.maxAge)
+
+	if !c.optionsPassthrough {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (c *Cors) handleActual(w http.ResponseWriter, r *http.Request) {
+	headers := w.Header()
+	origin := r.Header.Get("Origin")
+
+	headers.Add("Vary", "Origin")
+
+	if !c.isOriginAllowed(origin) {
+		return
+	}
+
+	c.setAllowOrigin(headers, origin)
+	if c.exposedHeaders != "" {
+		headers.Set("Access-Control-Expose-Headers", c.exposedHeaders)
+	}
+	if c.allowCredentials {
+		headers.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+func (c *Cors) setAllowOrigin(headers http.Header, origin string) {
+	if c.allowAllOrigins {
+		headers.Set("Access-Control-Allow-Origin", "*")
+		return
+	}
+	headers.Set("Access-Control-Allow-Origin", origin)
+}
+
+func (c *Cors) isOriginAllowed(origin string) bool {
+	if origin == "" {
+		return false
+	}
+	if c.allowedOriginFunc != nil {
+		return c.allowedOriginFunc(origin)
+	}
+	if c.allowAllOrigins {
+		return true
+	}
+	lower := strings.ToLower(origin)
+	for _, o := range c.allowedOrigins {
+		if o == lower {
+			return true
+		}
+	}
+	for _, w := range c.allowedWildcards {
+		if w.match(lower) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseHeaderList(v string) []string {
+	if v == "" {
+		return nil
+	}
+	parts := strings.Split(v, ",")
+	out := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// wildcard matches a single "*" pattern, e.g. "https://*.example.com".
+type wildcard struct {
+	re *regexp.Regexp
+}
+
+func newWildcard(pattern string) wildcard {
+	parts := strings.SplitN(pattern, "*", 2)
+	re := regexp.MustCompile("^" + regexp.QuoteMeta(parts[0]) + ".*" + regexp.QuoteMeta(parts[1]) + "$")
+	return wildcard{re: re}
+}
+
+func (w wildcard) match(s string) bool {
+	return w.re.MatchString(s)
+}
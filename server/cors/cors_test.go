@@ -0,0 +1,174 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIsOriginAllowed(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		origin  string
+		allowed bool
+	}{
+		{
+			name:    "exact match",
+			cfg:     Config{AllowedOrigins: []string{"https://example.com"}},
+			origin:  "https://example.com",
+			allowed: true,
+		},
+		{
+			name:    "exact match is case-insensitive",
+			cfg:     Config{AllowedOrigins: []string{"https://Example.com"}},
+			origin:  "https://example.com",
+			allowed: true,
+		},
+		{
+			name:    "no match",
+			cfg:     Config{AllowedOrigins: []string{"https://example.com"}},
+			origin:  "https://evil.com",
+			allowed: false,
+		},
+		{
+			name:    "wildcard subdomain matches",
+			cfg:     Config{AllowedOrigins: []string{"https://*.example.com"}},
+			origin:  "https://api.example.com",
+			allowed: true,
+		},
+		{
+			name:    "wildcard subdomain does not match bare domain",
+			cfg:     Config{AllowedOrigins: []string{"https://*.example.com"}},
+			origin:  "https://example.com",
+			allowed: false,
+		},
+		{
+			name:    "wildcard subdomain does not match a different suffix",
+			cfg:     Config{AllowedOrigins: []string{"https://*.example.com"}},
+			origin:  "https://api.example.com.evil.com",
+			allowed: false,
+		},
+		{
+			name:    "star allows any origin",
+			cfg:     Config{AllowedOrigins: []string{"*"}},
+			origin:  "https://anything.test",
+			allowed: true,
+		},
+		{
+			name:    "empty origin is never allowed",
+			cfg:     Config{AllowedOrigins: []string{"*"}},
+			origin:  "",
+			allowed: false,
+		},
+		{
+			name: "AllowedOriginFunc takes precedence over AllowedOrigins",
+			cfg: Config{
+				AllowedOrigins:    []string{"https://example.com"},
+				AllowedOriginFunc: func(origin string) bool { return origin == "https://func.test" },
+			},
+			origin:  "https://func.test",
+			allowed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(tt.cfg)
+			if got := c.isOriginAllowed(tt.origin); got != tt.allowed {
+				t.Errorf("isOriginAllowed(%q) = %v, want %v", tt.origin, got, tt.allowed)
+			}
+		})
+	}
+}
+
+func TestNewPanicsOnCredentialsWithWildcardOrigin(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("New did not panic with AllowCredentials=true and AllowedOrigins=[\"*\"]")
+		}
+	}()
+	New(Config{AllowedOrigins: []string{"*"}, AllowCredentials: true})
+}
+
+func TestNewAllowsCredentialsWithExplicitOrigin(t *testing.T) {
+	defer func() {
+		if recover() != nil {
+			t.Fatal("New should not panic when AllowedOrigins does not contain a wildcard")
+		}
+	}()
+	New(Config{AllowedOrigins: []string{"https://example.com"}, AllowCredentials: true})
+}
+
+func TestPreflightNegotiation(t *testing.T) {
+	c := New(Config{
+		AllowedOrigins: []string{"https://example.com"},
+		AllowedMethods: []string{http.MethodGet, http.MethodPost},
+		AllowedHeaders: []string{"Content-Type"},
+	})
+
+	h := c.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("preflight request should not reach the wrapped handler")
+	}))
+
+	req := httptest.NewRequest(http.MethodOptions, "/things", nil)
+	req.Header.Set("Origin", "https://example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	req.Header.Set("Access-Control-Request-Headers", "Content-Type")
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusNoContent)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://example.com" {
+		t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, "https://example.com")
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Methods"); got != http.MethodPost {
+		t.Errorf("Access-Control-Allow-Methods = %q, want %q", got, http.MethodPost)
+	}
+}
+
+func TestPreflightRejectsDisallowedMethod(t *testing.T) {
+	c := New(Config{
+		AllowedOrigins: []string{"https://example.com"},
+		AllowedMethods: []string{http.MethodGet},
+	})
+
+	h := c.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	req := httptest.NewRequest(http.MethodOptions, "/things", nil)
+	req.Header.Set("Origin", "https://example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodDelete)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("Access-Control-Allow-Methods"); got != "" {
+		t.Errorf("Access-Control-Allow-Methods = %q, want empty for a disallowed method", got)
+	}
+}
+
+func TestOptionsPassthrough(t *testing.T) {
+	called := false
+	c := New(Config{
+		AllowedOrigins:     []string{"https://example.com"},
+		AllowedMethods:     []string{http.MethodGet},
+		OptionsPassthrough: true,
+	})
+	h := c.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+
+	req := httptest.NewRequest(http.MethodOptions, "/things", nil)
+	req.Header.Set("Origin", "https://example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if !called {
+		t.Error("wrapped handler was not called with OptionsPassthrough enabled")
+	}
+}
@@ -0,0 +1,227 @@
+// Package gateway builds the grpc-gateway HTTP mux that fronts one or
+// more gRPC services. It takes over the dialing and mux setup that the
+// gateway command's newGateway function previously did inline, as a
+// Gateway type configured via functional options, so downstream users can
+// register additional services and middleware without forking the command.
+package gateway
+
+import (
+	"fmt"
+	"io/fs"
+	"net/http"
+	"path"
+	"strconv"
+
+	"github.com/grpc-ecosystem/grpc-gateway/runtime"
+	context "golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/aodin/grpc/server/httplog"
+)
+
+// ServiceHandler registers a generated Register*Handler function (the
+// grpc.ClientConn variant, not the *FromEndpoint one) against mux, using
+// the Gateway's shared upstream connection.
+type ServiceHandler func(ctx context.Context, mux *runtime.ServeMux, conn *grpc.ClientConn) error
+
+// Gateway builds an http.Handler that translates HTTP/JSON into gRPC for
+// one or more registered services.
+type Gateway struct {
+	endpoint     string
+	dialOpts     []grpc.DialOption
+	marshaler    runtime.Marshaler
+	services     []ServiceHandler
+	middleware   []func(http.Handler) http.Handler
+	errorHandler runtime.ErrorHandlerFunc
+
+	swaggerFS   fs.FS
+	swaggerPath string
+
+	conn *grpc.ClientConn
+}
+
+// Option configures a Gateway.
+type Option func(*Gateway)
+
+// WithEndpoint sets the upstream gRPC endpoint to dial, e.g.
+// "localhost:10000".
+func WithEndpoint(endpoint string) Option {
+	return func(g *Gateway) { g.endpoint = endpoint }
+}
+
+// WithDialOption appends a grpc.DialOption used when dialing the
+// upstream endpoint.
+func WithDialOption(opt grpc.DialOption) Option {
+	return func(g *Gateway) { g.dialOpts = append(g.dialOpts, opt) }
+}
+
+// WithTLS dials the upstream endpoint using TLS client credentials
+// loaded from certFile, verified against serverName.
+func WithTLS(certFile, serverName string) Option {
+	return func(g *Gateway) {
+		g.dialOpts = append(g.dialOpts, dialOptionOrPanic(certFile, serverName))
+	}
+}
+
+func dialOptionOrPanic(certFile, serverName string) grpc.DialOption {
+	creds, err := credentials.NewClientTLSFromFile(certFile, serverName)
+	if err != nil {
+		// Matches the fail-fast behavior of the TLS setup this replaces:
+		// a bad cert file is a startup-time configuration error.
+		panic(fmt.Sprintf("gateway: TLS creation failed: %v", err))
+	}
+	return grpc.WithTransportCredentials(creds)
+}
+
+// WithInsecure dials the upstream endpoint without transport security.
+func WithInsecure() Option {
+	return func(g *Gateway) { g.dialOpts = append(g.dialOpts, grpc.WithInsecure()) }
+}
+
+// WithMarshaler sets the runtime.Marshaler used to encode/decode the
+// JSON request and response bodies, e.g. runtime.JSONPb with
+// protojson.MarshalOptions{EmitUnpopulated: true, UseProtoNames: true}.
+func WithMarshaler(m runtime.Marshaler) Option {
+	return func(g *Gateway) { g.marshaler = m }
+}
+
+// WithServiceHandler registers a gRPC service's generated
+// Register*Handler function (see ServiceHandler) with the gateway mux.
+func WithServiceHandler(h ServiceHandler) Option {
+	return func(g *Gateway) { g.services = append(g.services, h) }
+}
+
+// WithMiddleware appends an http.Handler middleware applied, in the
+// order given, around the gateway mux.
+func WithMiddleware(m func(http.Handler) http.Handler) Option {
+	return func(g *Gateway) { g.middleware = append(g.middleware, m) }
+}
+
+// WithErrorHandler sets the runtime.ErrorHandlerFunc used to render
+// gRPC errors as HTTP responses.
+func WithErrorHandler(h runtime.ErrorHandlerFunc) Option {
+	return func(g *Gateway) { g.errorHandler = h }
+}
+
+// WithSwagger serves the generated OpenAPI/Swagger document and a
+// bundled Swagger UI from fs. path is the document's location within fs,
+// e.g. "things.swagger.json", and is served at
+// /openapi/<base name of path>. All of fs (the Swagger UI's static
+// assets, and the document itself) is also served under /docs/.
+func WithSwagger(fsys fs.FS, path string) Option {
+	return func(g *Gateway) {
+		g.swaggerFS = fsys
+		g.swaggerPath = path
+	}
+}
+
+// New builds a Gateway from the given options.
+func New(opts ...Option) *Gateway {
+	g := &Gateway{}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
+}
+
+// Build dials the upstream endpoint into a shared *grpc.ClientConn,
+// registers every service handler against a fresh runtime.ServeMux, and
+// returns the result wrapped with every configured middleware (applied
+// outermost-first, matching the order middleware was added). The
+// underlying connection is available via ClientConn and must be closed
+// with Close once the returned handler is no longer in use.
+func (g *Gateway) Build(ctx context.Context) (http.Handler, error) {
+	conn, err := grpc.DialContext(ctx, g.endpoint, g.dialOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("gateway: dial %s failed: %v", g.endpoint, err)
+	}
+	g.conn = conn
+
+	muxOpts := []runtime.ServeMuxOption{
+		runtime.WithMetadata(requestIDAnnotator),
+		runtime.WithForwardResponseOption(setOKGRPCStatusHeader),
+		runtime.WithErrorHandler(g.wrapErrorHandler()),
+	}
+	if g.marshaler != nil {
+		muxOpts = append(muxOpts, runtime.WithMarshalerOption(runtime.MIMEWildcard, g.marshaler))
+	}
+
+	mux := runtime.NewServeMux(muxOpts...)
+
+	for _, register := range g.services {
+		if err := register(ctx, mux, conn); err != nil {
+			return nil, err
+		}
+	}
+
+	top := http.NewServeMux()
+	top.Handle("/", mux)
+	top.Handle("/v1/services", servicesHandler(conn))
+	if g.swaggerFS != nil {
+		top.Handle("/openapi/"+path.Base(g.swaggerPath), swaggerHandler(g.swaggerFS, g.swaggerPath))
+		top.Handle("/docs/", http.StripPrefix("/docs/", http.FileServer(http.FS(g.swaggerFS))))
+	}
+
+	var handler http.Handler = top
+	for i := len(g.middleware) - 1; i >= 0; i-- {
+		handler = g.middleware[i](handler)
+	}
+	return handler, nil
+}
+
+// requestIDAnnotator forwards the X-Request-Id header set by
+// httplog.Middleware into outgoing gRPC metadata. runtime.ServeMux merges
+// annotator metadata with what runtime.AnnotateContext builds from the
+// incoming-header allowlist via metadata.Join, so this is the only
+// reliable way to get a header outside that allowlist to the backend.
+func requestIDAnnotator(ctx context.Context, r *http.Request) metadata.MD {
+	requestID := r.Header.Get(httplog.RequestIDHeader)
+	if requestID == "" {
+		return nil
+	}
+	return metadata.Pairs(httplog.RequestIDMetadataKey, requestID)
+}
+
+// setOKGRPCStatusHeader records codes.OK on every response grpc-gateway
+// forwards successfully, since a successful call has no error to read a
+// status out of. wrapErrorHandler records the real code for the
+// unsuccessful case.
+func setOKGRPCStatusHeader(ctx context.Context, w http.ResponseWriter, _ proto.Message) error {
+	w.Header().Set(httplog.GRPCStatusHeader, strconv.Itoa(int(codes.OK)))
+	return nil
+}
+
+// wrapErrorHandler returns a runtime.ErrorHandlerFunc that records the
+// RPC's real gRPC status code (status.Convert(err).Code(), the only
+// place that code is still available once grpc-go has turned the
+// HTTP/2 grpc-status trailer into a Go error) before delegating to
+// g.errorHandler, or runtime.DefaultHTTPError if none was set.
+func (g *Gateway) wrapErrorHandler() runtime.ErrorHandlerFunc {
+	next := g.errorHandler
+	if next == nil {
+		next = runtime.DefaultHTTPError
+	}
+	return func(ctx context.Context, mux *runtime.ServeMux, marshaler runtime.Marshaler, w http.ResponseWriter, r *http.Request, err error) {
+		w.Header().Set(httplog.GRPCStatusHeader, strconv.Itoa(int(status.Convert(err).Code())))
+		next(ctx, mux, marshaler, w, r, err)
+	}
+}
+
+// ClientConn returns the upstream connection dialed by Build. It is nil
+// until Build has been called successfully.
+func (g *Gateway) ClientConn() *grpc.ClientConn {
+	return g.conn
+}
+
+// Close closes the upstream connection dialed by Build.
+func (g *Gateway) Close() error {
+	if g.conn == nil {
+		return nil
+	}
+	return g.conn.Close()
+}
@@ -0,0 +1,117 @@
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	context "golang.org/x/net/context"
+	"google.golang.org/grpc"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// reflectionTimeout bounds how long a /v1/services request waits on the
+// upstream reflection service.
+const reflectionTimeout = 5 * time.Second
+
+// service describes a gRPC service discovered via server reflection.
+type service struct {
+	Name    string   `json:"name"`
+	Methods []string `json:"methods"`
+}
+
+// servicesHandler answers /v1/services by querying conn's gRPC
+// reflection API, so frontends can discover available services and
+// methods without a separate proto build.
+func servicesHandler(conn *grpc.ClientConn) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), reflectionTimeout)
+		defer cancel()
+
+		services, err := listServices(ctx, conn)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("reflection: %v", err), http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(services)
+	}
+}
+
+// listServices queries conn's reflection service for the list of
+// registered services and, for each, the methods defined on it. The
+// reflection service itself is left out of the result.
+func listServices(ctx context.Context, conn *grpc.ClientConn) ([]service, error) {
+	stream, err := rpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.CloseSend()
+
+	if err := stream.Send(&rpb.ServerReflectionRequest{
+		MessageRequest: &rpb.ServerReflectionRequest_ListServices{},
+	}); err != nil {
+		return nil, err
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		return nil, err
+	}
+	listResp := resp.GetListServicesResponse()
+	if listResp == nil {
+		return nil, fmt.Errorf("unexpected response to ListServices: %T", resp.MessageResponse)
+	}
+
+	var services []service
+	for _, s := range listResp.Service {
+		if s.Name == "grpc.reflection.v1alpha.ServerReflection" {
+			continue
+		}
+		methods, err := serviceMethods(stream, s.Name)
+		if err != nil {
+			return nil, err
+		}
+		services = append(services, service{Name: s.Name, Methods: methods})
+	}
+	return services, nil
+}
+
+// serviceMethods fetches the file descriptor containing serviceName and
+// returns the names of the methods it defines.
+func serviceMethods(stream rpb.ServerReflection_ServerReflectionInfoClient, serviceName string) ([]string, error) {
+	if err := stream.Send(&rpb.ServerReflectionRequest{
+		MessageRequest: &rpb.ServerReflectionRequest_FileContainingSymbol{
+			FileContainingSymbol: serviceName,
+		},
+	}); err != nil {
+		return nil, err
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		return nil, err
+	}
+	fdResp := resp.GetFileDescriptorResponse()
+	if fdResp == nil {
+		return nil, fmt.Errorf("unexpected response to FileContainingSymbol(%s): %T", serviceName, resp.MessageResponse)
+	}
+
+	var methods []string
+	for _, raw := range fdResp.FileDescriptorProto {
+		fd := &descriptorpb.FileDescriptorProto{}
+		if err := proto.Unmarshal(raw, fd); err != nil {
+			return nil, err
+		}
+		for _, svc := range fd.GetService() {
+			if fd.GetPackage()+"."+svc.GetName() != serviceName {
+				continue
+			}
+			for _, m := range svc.GetMethod() {
+				methods = append(methods, m.GetName())
+			}
+		}
+	}
+	return methods, nil
+}
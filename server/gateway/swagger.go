@@ -0,0 +1,20 @@
+package gateway
+
+import (
+	"io/fs"
+	"net/http"
+)
+
+// swaggerHandler serves the single OpenAPI/Swagger document at path
+// within fsys.
+func swaggerHandler(fsys fs.FS, path string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, err := fs.ReadFile(fsys, path)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	})
+}
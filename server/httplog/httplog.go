@@ -0,0 +1,175 @@
+// Package httplog provides structured HTTP access logging middleware for
+// the gateway, replacing the previous plain-text logRequestWrapper. It
+// assigns/propagates an X-Request-ID on every request and records the
+// gRPC status code the gateway forwarded to the client.
+package httplog
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// RequestIDHeader is the HTTP header used to read and propagate the
+// request ID.
+const RequestIDHeader = "X-Request-ID"
+
+// RequestIDMetadataKey is the gRPC metadata key the request ID is
+// forwarded under so it shows up in backend logs.
+const RequestIDMetadataKey = "x-request-id"
+
+// GRPCStatusHeader is the response header gateway.Gateway sets (via a
+// runtime.WithErrorHandler/WithForwardResponseOption pair) to the gRPC
+// status code of the RPC the request was forwarded to. It is not a
+// header grpc-gateway sets on its own: the real grpc-status is consumed
+// internally by the gRPC client transport and turned into the call's
+// returned error, never exposed as response metadata.
+const GRPCStatusHeader = "X-Grpc-Status"
+
+// Format selects the encoding used for access log lines.
+type Format string
+
+const (
+	// FormatText renders logs with slog's default text handler.
+	FormatText Format = "text"
+	// FormatJSON renders logs as newline-delimited JSON.
+	FormatJSON Format = "json"
+)
+
+// Option configures a Middleware.
+type Option func(*Middleware)
+
+// WithLogger sets the slog.Logger used to emit access log entries,
+// allowing callers to inject zap, zerolog, or any other slog.Handler
+// based logger. Defaults to a new logger writing to os.Stdout in the
+// format selected by WithFormat.
+func WithLogger(logger *slog.Logger) Option {
+	return func(m *Middleware) { m.logger = logger }
+}
+
+// WithFormat selects the default logger's output format. It has no
+// effect if WithLogger was also given. Defaults to FormatJSON.
+func WithFormat(format Format) Option {
+	return func(m *Middleware) { m.format = format }
+}
+
+// FormatFromEnv reads LOG_FORMAT ("text" or "json") and returns the
+// corresponding Format, defaulting to FormatJSON.
+func FormatFromEnv() Format {
+	if Format(os.Getenv("LOG_FORMAT")) == FormatText {
+		return FormatText
+	}
+	return FormatJSON
+}
+
+// Middleware is structured access-log middleware for an http.Handler.
+type Middleware struct {
+	logger *slog.Logger
+	format Format
+}
+
+// New builds a Middleware from the given options.
+func New(opts ...Option) *Middleware {
+	m := &Middleware{format: FormatJSON}
+	for _, opt := range opts {
+		opt(m)
+	}
+	if m.logger == nil {
+		var handler slog.Handler
+		if m.format == FormatText {
+			handler = slog.NewTextHandler(os.Stdout, nil)
+		} else {
+			handler = slog.NewJSONHandler(os.Stdout, nil)
+		}
+		m.logger = slog.New(handler)
+	}
+	return m
+}
+
+// Wrap returns h wrapped with access logging. It assigns a request ID if
+// the caller did not supply one, sets it on both the request and
+// response headers (so gateway.Gateway's runtime.WithMetadata annotator
+// can forward it to the backend as gRPC metadata), and logs the method,
+// path, gRPC/HTTP status, and latency once h returns.
+func (m *Middleware) Wrap(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(RequestIDHeader)
+		if requestID == "" {
+			requestID = newRequestID()
+			r.Header.Set(RequestIDHeader, requestID)
+		}
+		w.Header().Set(RequestIDHeader, requestID)
+
+		writer := &statusWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		start := time.Now()
+		h.ServeHTTP(writer, r)
+		elapsed := time.Since(start)
+
+		m.logger.LogAttrs(r.Context(), slog.LevelInfo, "request",
+			slog.String("request_id", requestID),
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", writer.statusCode),
+			slog.String("grpc_status", writer.Header().Get(GRPCStatusHeader)),
+			slog.Float64("duration_seconds", elapsed.Seconds()),
+			slog.Int64("duration_bucket_ms", bucketMillis(elapsed)),
+		)
+	})
+}
+
+// statusWriter records the status code of the response, as the removed
+// loggingResponseWriter did.
+type statusWriter struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	w.statusCode = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// bucketMillis rounds elapsed up to the nearest power-of-two
+// millisecond bucket (0 for under a millisecond), producing a field that is cheap to aggregate into
+// a histogram downstream.
+func bucketMillis(elapsed time.Duration) int64 {
+	ms := elapsed.Milliseconds()
+	if ms <= 0 {
+		return 0
+	}
+	bucket := int64(1)
+	for bucket < ms {
+		bucket <<= 1
+	}
+	return bucket
+}
+
+func newRequestID() string {
+	var buf [16]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return hex.EncodeToString([]byte(time.Now().String()))[:32]
+	}
+	return hex.EncodeToString(buf[:])
+}
+
+// IncomingRequestID extracts the request ID forwarded by the gateway, for
+// use by backend gRPC services that want to log it. It reads incoming
+// metadata, since on the server side the gateway's outgoing metadata
+// arrives as incoming metadata.
+func IncomingRequestID(ctx context.Context) (string, bool) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", false
+	}
+	values := md.Get(RequestIDMetadataKey)
+	if len(values) == 0 {
+		return "", false
+	}
+	return values[0], true
+}